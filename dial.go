@@ -0,0 +1,56 @@
+package name
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// dialPerIPTimeout 单个IP的连接超时时间
+const dialPerIPTimeout = 2 * time.Second
+
+// DialContext 实现拨号函数签名，可直接用作http.Transport.DialContext，
+// 利用缓存的DNS结果依次尝试每个IP直到连接成功
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("DialContext: invalid address %s: %w", address, err)
+	}
+
+	conn, err := r.dialHost(ctx, network, host, port)
+	if err != nil {
+		// 全部地址都失败：清除缓存并重新解析一次再试
+		r.cache.Delete(host)
+		conn, err = r.dialHost(ctx, network, host, port)
+	}
+	return conn, err
+}
+
+// Dial 是DialContext的便捷封装，使用context.Background()
+func (r *Resolver) Dial(network, address string) (net.Conn, error) {
+	return r.DialContext(context.Background(), network, address)
+}
+
+// dialHost 依次尝试host解析出的每个IP，任意一个拨号成功即返回
+func (r *Resolver) dialHost(ctx context.Context, network, host, port string) (net.Conn, error) {
+	ips, err := r.lookupHost(host)
+	if err != nil {
+		return nil, err
+	}
+	ips = r.orderIPs(host, ips)
+
+	dialer := &net.Dialer{}
+	var lastErr error
+	for _, ip := range ips {
+		dialCtx, cancel := context.WithTimeout(ctx, dialPerIPTimeout)
+		conn, dialErr := dialer.DialContext(dialCtx, network, net.JoinHostPort(ip.String(), port))
+		cancel()
+		if dialErr == nil {
+			return conn, nil
+		}
+		lastErr = dialErr
+	}
+
+	return nil, fmt.Errorf("dialHost: all addresses failed for %s: %w", host, lastErr)
+}
@@ -0,0 +1,98 @@
+package name
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer 定义解析过程中的可观测性回调，供外部导出Prometheus等指标或诊断日志
+type Observer interface {
+	OnCacheHit(host string)
+	OnCacheMiss(host string)
+	OnStaleRefresh(host string)
+	OnResolveError(host string, err error)
+	// OnUpstreamLatency 在每次访问某个上游DNS服务器后触发，无论成功与否。
+	// DoT/DoH（lookupUpstreams）路径上d为完整查询的耗时；明文UDP路径
+	// （NewResolver的自定义Dial）上d只是建立UDP套接字的拨号耗时，不含查询往返时间
+	OnUpstreamLatency(server string, d time.Duration)
+}
+
+// UpstreamStats 记录单个上游服务器的累计成功/失败次数
+type UpstreamStats struct {
+	Success uint64
+	Failure uint64
+}
+
+// Stats 汇总Resolver的累计运行指标
+type Stats struct {
+	Hits           uint64
+	Misses         uint64
+	StaleRefreshes uint64
+	Errors         uint64
+	NegativeHits   uint64
+	Upstreams      map[string]UpstreamStats
+}
+
+// SetObserver 设置观测回调，传入nil可取消订阅
+func (r *Resolver) SetObserver(observer Observer) {
+	r.observerMu.Lock()
+	r.observer = observer
+	r.observerMu.Unlock()
+}
+
+// getObserver 读取当前观测回调
+func (r *Resolver) getObserver() Observer {
+	r.observerMu.RLock()
+	defer r.observerMu.RUnlock()
+	return r.observer
+}
+
+// Stats 返回当前累计的运行指标快照
+func (r *Resolver) Stats() Stats {
+	upstreams := make(map[string]UpstreamStats, len(r.upstreams))
+	for _, u := range r.upstreams {
+		upstreams[u.addr] = UpstreamStats{
+			Success: u.successCount.Load(),
+			Failure: u.failureCount.Load(),
+		}
+	}
+
+	return Stats{
+		Hits:           atomic.LoadUint64(&r.hits),
+		Misses:         atomic.LoadUint64(&r.misses),
+		StaleRefreshes: atomic.LoadUint64(&r.staleRefreshes),
+		Errors:         atomic.LoadUint64(&r.errors),
+		NegativeHits:   atomic.LoadUint64(&r.negHits),
+		Upstreams:      upstreams,
+	}
+}
+
+func (r *Resolver) notifyCacheHit(host string) {
+	if obs := r.getObserver(); obs != nil {
+		obs.OnCacheHit(host)
+	}
+}
+
+func (r *Resolver) notifyCacheMiss(host string) {
+	if obs := r.getObserver(); obs != nil {
+		obs.OnCacheMiss(host)
+	}
+}
+
+func (r *Resolver) notifyStaleRefresh(host string) {
+	if obs := r.getObserver(); obs != nil {
+		obs.OnStaleRefresh(host)
+	}
+}
+
+func (r *Resolver) notifyResolveError(host string, err error) {
+	if obs := r.getObserver(); obs != nil {
+		obs.OnResolveError(host, err)
+	}
+}
+
+func (r *Resolver) notifyUpstreamLatency(server string, d time.Duration) {
+	if obs := r.getObserver(); obs != nil {
+		obs.OnUpstreamLatency(server, d)
+	}
+}
@@ -14,24 +14,50 @@ import (
 const (
 	dnsTimeout       = 5 * time.Second
 	defaultTTL       = 5 * time.Minute
+	defaultNegTTL    = 10 * time.Second
 	defaultDNSPort   = ":53"
 	refreshThreshold = 80
 )
 
 // cacheEntry 缓存条目
 type cacheEntry struct {
-	ips     []net.IP  // 解析的IP地址
-	expires time.Time // 缓存过期时间
-	stale   time.Time // 后台刷新时间
+	ips        []net.IP  // 解析的IP地址
+	err        error     // 解析失败时的错误（负缓存）
+	expires    time.Time // 缓存过期时间
+	negExpires time.Time // 负缓存过期时间
+	stale      time.Time // 后台刷新时间
+	rrCounter  uint32    // RoundRobin策略下的轮询计数器
+}
+
+// call 表示一次正在进行的DNS解析，用于合并并发的相同查询
+type call struct {
+	wg  sync.WaitGroup
+	ips []net.IP
+	err error
 }
 
 // Resolver DNS解析器结构体
 type Resolver struct {
-	cache       sync.Map      // 缓存映射
-	ttl         time.Duration // 缓存时间
-	dnsServers  []string      // DNS地址组
-	serverIndex uint32        // 轮询索引
-	netResolver *net.Resolver // 底层解析器
+	cache              sync.Map        // 缓存映射
+	inflight           sync.Map        // 正在进行的解析请求，用于singleflight合并
+	ttl                time.Duration   // 缓存时间
+	negTTL             time.Duration   // 负缓存时间（解析失败时）
+	dnsServers         []string        // DNS地址组
+	serverIndex        uint32          // 轮询索引
+	netResolver        *net.Resolver   // 底层解析器
+	upstreams          []*upstream     // 支持DoT/DoH时的上游服务器列表
+	useManualUpstreams bool            // 是否绕过net.Resolver，手动处理DoT/DoH查询
+	queryID            uint32          // DNS查询报文ID计数器
+	policy             SelectionPolicy // 地址选择策略
+
+	observerMu sync.RWMutex // 保护observer的并发访问
+	observer   Observer     // 可观测性回调
+
+	hits           uint64 // 累计缓存命中次数
+	misses         uint64 // 累计缓存未命中次数
+	staleRefreshes uint64 // 累计后台刷新触发次数
+	errors         uint64 // 累计解析失败次数
+	negHits        uint64 // 累计负缓存命中次数
 }
 
 // NewResolver 创建新的DNS解析器
@@ -42,6 +68,7 @@ func NewResolver(ttl time.Duration, dnsServers []string) *Resolver {
 
 	resolver := &Resolver{
 		ttl:        ttl,
+		negTTL:     defaultNegTTL,
 		dnsServers: dnsServers,
 	}
 
@@ -58,8 +85,10 @@ func NewResolver(ttl time.Duration, dnsServers []string) *Resolver {
 
 					dialCtx, dialCancel := context.WithTimeout(context.Background(), dnsTimeout)
 					dialer := &net.Dialer{}
+					start := time.Now()
 					conn, err := dialer.DialContext(dialCtx, "udp", server)
 					dialCancel()
+					resolver.notifyUpstreamLatency(server, time.Since(start))
 
 					if err == nil {
 						return conn, nil
@@ -86,36 +115,88 @@ func (r *Resolver) lookupHost(host string) ([]net.IP, error) {
 
 	if entry, ok := r.cache.Load(host); ok {
 		ce := entry.(*cacheEntry)
-		if now.Before(ce.expires) {
-			// 后台刷新：超过阈值时触发
+		if ce.err != nil {
+			// 负缓存命中：在负缓存有效期内直接返回缓存的错误
+			if now.Before(ce.negExpires) {
+				atomic.AddUint64(&r.negHits, 1)
+				return nil, ce.err
+			}
+			r.cache.Delete(host)
+		} else if now.Before(ce.expires) {
+			atomic.AddUint64(&r.hits, 1)
+			r.notifyCacheHit(host)
+			// 后台刷新：超过阈值时触发，经由singleflight合并
 			if now.After(ce.stale) {
-				go r.resolveDNS(host)
+				atomic.AddUint64(&r.staleRefreshes, 1)
+				r.notifyStaleRefresh(host)
+				go r.resolveDNSShared(host)
 			}
 			return ce.ips, nil
+		} else {
+			// 缓存过期
+			r.cache.Delete(host)
 		}
-		// 缓存过期
-		r.cache.Delete(host)
 	}
 
-	return r.resolveDNS(host)
+	atomic.AddUint64(&r.misses, 1)
+	r.notifyCacheMiss(host)
+	return r.resolveDNSShared(host)
+}
+
+// resolveDNSShared 对相同host的并发解析请求进行singleflight合并，
+// 避免缓存失效或后台刷新瞬间出现的解析风暴
+func (r *Resolver) resolveDNSShared(host string) ([]net.IP, error) {
+	if existing, ok := r.inflight.Load(host); ok {
+		c := existing.(*call)
+		c.wg.Wait()
+		return c.ips, c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	actual, loaded := r.inflight.LoadOrStore(host, c)
+	if loaded {
+		c = actual.(*call)
+		c.wg.Wait()
+		return c.ips, c.err
+	}
+
+	c.ips, c.err = r.resolveDNS(host)
+	c.wg.Done()
+	r.inflight.Delete(host)
+	return c.ips, c.err
 }
 
-// resolveDNS 执行实际的DNS解析并缓存结果
+// resolveDNS 执行实际的DNS解析并缓存结果，失败时写入负缓存
 func (r *Resolver) resolveDNS(host string) ([]net.IP, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), dnsTimeout)
 	defer cancel()
 
-	ips, err := r.netResolver.LookupIP(ctx, "ip", host)
+	var ips []net.IP
+	var err error
+	if r.useManualUpstreams {
+		ips, err = r.lookupUpstreams(ctx, host)
+	} else {
+		ips, err = r.netResolver.LookupIP(ctx, "ip", host)
+	}
+	now := time.Now()
 	if err != nil {
-		return nil, fmt.Errorf("resolveDNS: lookup failed for %s: %w", host, err)
+		err = fmt.Errorf("resolveDNS: lookup failed for %s: %w", host, err)
+		atomic.AddUint64(&r.errors, 1)
+		r.notifyResolveError(host, err)
+		r.cache.Store(host, &cacheEntry{err: err, negExpires: now.Add(r.negTTL)})
+		return nil, err
 	}
 
 	if len(ips) == 0 {
-		return nil, fmt.Errorf("resolveDNS: no IP addresses found for %s", host)
+		err = fmt.Errorf("resolveDNS: no IP addresses found for %s", host)
+		atomic.AddUint64(&r.errors, 1)
+		r.notifyResolveError(host, err)
+		r.cache.Store(host, &cacheEntry{err: err, negExpires: now.Add(r.negTTL)})
+		return nil, err
 	}
 
 	// 缓存结果
-	now := time.Now()
 	r.cache.Store(host, &cacheEntry{
 		ips:     ips,
 		expires: now.Add(r.ttl),
@@ -142,33 +223,32 @@ func (r *Resolver) resolveAddr(network, address string) (net.IP, int, error) {
 		return nil, 0, err
 	}
 
-	var selectedIP net.IP
+	var candidates []net.IP
 	switch network {
 	case "tcp4", "udp4":
 		for _, ip := range ips {
 			if ip.To4() != nil {
-				selectedIP = ip
-				break
+				candidates = append(candidates, ip)
 			}
 		}
-		if selectedIP == nil {
+		if len(candidates) == 0 {
 			return nil, 0, fmt.Errorf("resolveAddr: no IPv4 address found for %s", host)
 		}
 	case "tcp6", "udp6":
 		for _, ip := range ips {
 			if ip.To4() == nil && ip.To16() != nil {
-				selectedIP = ip
-				break
+				candidates = append(candidates, ip)
 			}
 		}
-		if selectedIP == nil {
+		if len(candidates) == 0 {
 			return nil, 0, fmt.Errorf("resolveAddr: no IPv6 address found for %s", host)
 		}
 	default:
-		selectedIP = ips[0]
+		candidates = ips
 	}
 
-	return selectedIP, portNum, nil
+	selected := r.orderIPs(host, candidates)
+	return selected[0], portNum, nil
 }
 
 // ResolveTCPAddr 解析TCP地址
@@ -227,7 +307,7 @@ func (r *Resolver) RefreshCache() {
 	})
 
 	for _, host := range hosts {
-		go r.resolveDNS(host)
+		go r.resolveDNSShared(host)
 	}
 }
 
@@ -250,3 +330,13 @@ func (r *Resolver) SetTTL(ttl time.Duration) {
 func (r *Resolver) GetTTL() time.Duration {
 	return r.ttl
 }
+
+// SetNegativeTTL 动态调整负缓存时间（解析失败的缓存窗口）
+func (r *Resolver) SetNegativeTTL(ttl time.Duration) {
+	r.negTTL = ttl
+}
+
+// GetNegativeTTL 获取当前负缓存时间配置
+func (r *Resolver) GetNegativeTTL() time.Duration {
+	return r.negTTL
+}
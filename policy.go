@@ -0,0 +1,210 @@
+package name
+
+import (
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// SelectionPolicy 定义从多个解析结果中选择/排序地址的策略
+type SelectionPolicy int
+
+const (
+	FirstIP    SelectionPolicy = iota // 始终使用解析结果中的第一个IP
+	RoundRobin                        // 按host轮询，实现客户端负载均衡
+	Random                            // 每次随机选择
+	RFC6724                           // 按RFC 6724目的地址选择规则排序
+)
+
+// SetSelectionPolicy 设置地址选择策略
+func (r *Resolver) SetSelectionPolicy(policy SelectionPolicy) {
+	r.policy = policy
+}
+
+// GetSelectionPolicy 获取当前地址选择策略
+func (r *Resolver) GetSelectionPolicy() SelectionPolicy {
+	return r.policy
+}
+
+// LookupIPs 返回host解析得到的全部IP，已按当前选择策略排序
+func (r *Resolver) LookupIPs(host string) ([]net.IP, error) {
+	ips, err := r.lookupHost(host)
+	if err != nil {
+		return nil, err
+	}
+	return r.orderIPs(host, ips), nil
+}
+
+// orderIPs 按当前策略对候选IP列表重新排序，不会修改缓存数据。RoundRobin、Random、
+// RFC6724返回新切片；FirstIP策略或候选少于2个时直接返回传入的切片本身
+func (r *Resolver) orderIPs(host string, ips []net.IP) []net.IP {
+	if len(ips) < 2 {
+		return ips
+	}
+
+	switch r.policy {
+	case RoundRobin:
+		return rotateIPs(ips, r.nextRoundRobinOffset(host, len(ips)))
+	case Random:
+		return shuffleIPs(ips)
+	case RFC6724:
+		return sortRFC6724(ips)
+	default:
+		return ips
+	}
+}
+
+// nextRoundRobinOffset 读取并递增host对应缓存条目上的轮询计数器
+func (r *Resolver) nextRoundRobinOffset(host string, n int) int {
+	entry, ok := r.cache.Load(host)
+	if !ok {
+		return 0
+	}
+	ce := entry.(*cacheEntry)
+	offset := atomic.AddUint32(&ce.rrCounter, 1) - 1
+	return int(offset) % n
+}
+
+// rotateIPs 返回从offset开始循环排列的新切片
+func rotateIPs(ips []net.IP, offset int) []net.IP {
+	n := len(ips)
+	rotated := make([]net.IP, n)
+	for i := 0; i < n; i++ {
+		rotated[i] = ips[(i+offset)%n]
+	}
+	return rotated
+}
+
+// shuffleIPs 返回随机打乱顺序的新切片
+func shuffleIPs(ips []net.IP) []net.IP {
+	shuffled := make([]net.IP, len(ips))
+	copy(shuffled, ips)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// sortRFC6724 按RFC 6724目的地址选择规则对候选地址排序（范围、优先级、
+// 与源地址的最长公共前缀），简化实现仅覆盖最常用的几条规则
+func sortRFC6724(ips []net.IP) []net.IP {
+	type candidate struct {
+		ip         net.IP
+		scope      int
+		scopeMatch bool // 目的地址范围是否与其源地址范围一致
+		precOrder  int
+		src        net.IP
+	}
+
+	candidates := make([]candidate, len(ips))
+	for i, ip := range ips {
+		src := sourceAddrFor(ip)
+		candidates[i] = candidate{
+			ip:         ip,
+			scope:      addressScope(ip),
+			scopeMatch: src != nil && addressScope(ip) == addressScope(src),
+			precOrder:  addressPrecedence(ip),
+			src:        src,
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		// 规则2：优先选择范围与源地址范围相匹配的目的地址
+		if a.scopeMatch != b.scopeMatch {
+			return a.scopeMatch
+		}
+		// 规则6：优先选择策略表中优先级更高的地址族
+		if a.precOrder != b.precOrder {
+			return a.precOrder > b.precOrder
+		}
+		// 规则8：以上规则相同时，优先选择范围更小（更具体）的目的地址
+		if a.scope != b.scope {
+			return a.scope < b.scope
+		}
+		// 规则9：优先选择与本机源地址公共前缀更长的目的地址
+		return commonPrefixLen(a.ip, a.src) > commonPrefixLen(b.ip, b.src)
+	})
+
+	sorted := make([]net.IP, len(candidates))
+	for i, c := range candidates {
+		sorted[i] = c.ip
+	}
+	return sorted
+}
+
+// addressScope 近似RFC 6724中的地址范围（值越小范围越受限）
+func addressScope(ip net.IP) int {
+	switch {
+	case ip.IsLoopback():
+		return 0
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return 2
+	case ip.IsPrivate():
+		return 5
+	default:
+		return 14
+	}
+}
+
+// addressPrecedence 近似RFC 6724策略表，优先使用原生地址族
+func addressPrecedence(ip net.IP) int {
+	if ip.To4() != nil {
+		return 35
+	}
+	return 40
+}
+
+// sourceAddrCache 缓存目的地址到出口源地址的映射，避免RFC6724排序在每次
+// orderIPs调用时都为同一目的地址重新拨号查路由
+var sourceAddrCache sync.Map
+
+// sourceAddrFor 通过向目的地址发起一次不落地的UDP拨号来获取本机的出口源地址，
+// 近似net.Dialer的路由查找行为；结果按目的地址缓存，因为本机路由极少变化
+func sourceAddrFor(dst net.IP) net.IP {
+	key := dst.String()
+	if cached, ok := sourceAddrCache.Load(key); ok {
+		src, _ := cached.(net.IP)
+		return src
+	}
+
+	var src net.IP
+	conn, err := net.Dial("udp", net.JoinHostPort(key, "80"))
+	if err == nil {
+		defer conn.Close()
+		if udpAddr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			src = udpAddr.IP
+		}
+	}
+
+	sourceAddrCache.Store(key, src)
+	return src
+}
+
+// commonPrefixLen 计算两个IP地址按位比较的公共前缀长度
+func commonPrefixLen(a, b net.IP) int {
+	if b == nil {
+		return 0
+	}
+	a16, b16 := a.To16(), b.To16()
+	if a16 == nil || b16 == nil {
+		return 0
+	}
+
+	count := 0
+	for i := range a16 {
+		xor := a16[i] ^ b16[i]
+		if xor == 0 {
+			count += 8
+			continue
+		}
+		for xor&0x80 == 0 {
+			count++
+			xor <<= 1
+		}
+		break
+	}
+	return count
+}
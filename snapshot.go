@@ -0,0 +1,170 @@
+package name
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// snapshotVersion 快照格式版本号，递增以兼容未来格式变更
+const snapshotVersion byte = 1
+
+// snapshotEntry 是cacheEntry的可序列化形式
+type snapshotEntry struct {
+	Host    string    `json:"host"`
+	IPs     []string  `json:"ips"`
+	Expires time.Time `json:"expires"`
+	Stale   time.Time `json:"stale"`
+}
+
+// SaveSnapshot 将当前缓存（不含负缓存条目）写出为长度前缀的JSON行格式，
+// 首字节为格式版本号
+func (r *Resolver) SaveSnapshot(w io.Writer) error {
+	if _, err := w.Write([]byte{snapshotVersion}); err != nil {
+		return fmt.Errorf("SaveSnapshot: write version failed: %w", err)
+	}
+
+	var saveErr error
+	r.cache.Range(func(key, value any) bool {
+		ce := value.(*cacheEntry)
+		if ce.err != nil {
+			return true
+		}
+
+		entry := snapshotEntry{
+			Host:    key.(string),
+			IPs:     ipsToStrings(ce.ips),
+			Expires: ce.expires,
+			Stale:   ce.stale,
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			saveErr = fmt.Errorf("SaveSnapshot: marshal %s failed: %w", entry.Host, err)
+			return false
+		}
+
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(data)))
+		if _, err := w.Write(length[:]); err != nil {
+			saveErr = fmt.Errorf("SaveSnapshot: write length failed: %w", err)
+			return false
+		}
+		if _, err := w.Write(data); err != nil {
+			saveErr = fmt.Errorf("SaveSnapshot: write entry failed: %w", err)
+			return false
+		}
+		return true
+	})
+
+	return saveErr
+}
+
+// LoadSnapshot 从长度前缀的JSON行格式恢复缓存，已过期的条目会被跳过
+func (r *Resolver) LoadSnapshot(reader io.Reader) error {
+	var version [1]byte
+	if _, err := io.ReadFull(reader, version[:]); err != nil {
+		return fmt.Errorf("LoadSnapshot: read version failed: %w", err)
+	}
+	if version[0] > snapshotVersion {
+		return fmt.Errorf("LoadSnapshot: unsupported snapshot version %d", version[0])
+	}
+
+	now := time.Now()
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(reader, length[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("LoadSnapshot: read length failed: %w", err)
+		}
+
+		data := make([]byte, binary.BigEndian.Uint32(length[:]))
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return fmt.Errorf("LoadSnapshot: read entry failed: %w", err)
+		}
+
+		var entry snapshotEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("LoadSnapshot: unmarshal entry failed: %w", err)
+		}
+
+		if !now.Before(entry.Expires) {
+			continue
+		}
+
+		ips, err := stringsToIPs(entry.IPs)
+		if err != nil {
+			return fmt.Errorf("LoadSnapshot: invalid IP in entry for %s: %w", entry.Host, err)
+		}
+
+		r.cache.Store(entry.Host, &cacheEntry{
+			ips:     ips,
+			expires: entry.Expires,
+			stale:   entry.Stale,
+		})
+	}
+
+	return nil
+}
+
+// LoadSnapshotFile 打开path并调用LoadSnapshot恢复缓存
+func (r *Resolver) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("LoadSnapshotFile: open %s failed: %w", path, err)
+	}
+	defer f.Close()
+
+	return r.LoadSnapshot(f)
+}
+
+// AutoPersist 启动一个后台goroutine，按interval周期将缓存快照写入path，
+// 便于进程重启后快速恢复、跳过冷缓存延迟
+func (r *Resolver) AutoPersist(path string, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			r.flushSnapshot(path)
+		}
+	}()
+}
+
+// flushSnapshot 尽力将当前缓存写入path，写入失败时静默忽略
+func (r *Resolver) flushSnapshot(path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	_ = r.SaveSnapshot(f)
+}
+
+// ipsToStrings 将IP切片转换为字符串切片，便于JSON序列化
+func ipsToStrings(ips []net.IP) []string {
+	out := make([]string, len(ips))
+	for i, ip := range ips {
+		out[i] = ip.String()
+	}
+	return out
+}
+
+// stringsToIPs 将字符串切片还原为IP切片
+func stringsToIPs(strs []string) ([]net.IP, error) {
+	ips := make([]net.IP, len(strs))
+	for i, s := range strs {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP %q", s)
+		}
+		ips[i] = ip
+	}
+	return ips, nil
+}
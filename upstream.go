@@ -0,0 +1,344 @@
+package name
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	upstreamCooldown = 30 * time.Second // 上游失败后的冷却时间
+	dotDefaultPort   = ":853"           // DNS-over-TLS默认端口
+)
+
+// upstreamScheme 表示上游DNS服务器使用的协议
+type upstreamScheme int
+
+const (
+	schemeUDP upstreamScheme = iota
+	schemeTLS
+	schemeHTTPS
+)
+
+// upstream 表示一个DNS上游服务器及其健康状态
+type upstream struct {
+	scheme       upstreamScheme
+	addr         string // udp/tls为host:port，https为完整的dns-query URL
+	failAt       atomic.Int64
+	successCount atomic.Uint64
+	failureCount atomic.Uint64
+}
+
+// healthy 判断该上游是否已度过冷却期
+func (u *upstream) healthy(now time.Time) bool {
+	fa := u.failAt.Load()
+	if fa == 0 {
+		return true
+	}
+	return now.After(time.Unix(0, fa).Add(upstreamCooldown))
+}
+
+func (u *upstream) markFailed() {
+	u.failAt.Store(time.Now().UnixNano())
+	u.failureCount.Add(1)
+}
+
+func (u *upstream) markOK() {
+	u.failAt.Store(0)
+	u.successCount.Add(1)
+}
+
+// parseUpstream 解析一条上游服务器配置，支持 "tls://host:port"（DoT）、
+// "https://host/path"（DoH）以及裸露的 "host" 或 "host:port"（明文UDP）
+func parseUpstream(spec string) *upstream {
+	switch {
+	case strings.HasPrefix(spec, "tls://"):
+		addr := strings.TrimPrefix(spec, "tls://")
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr += dotDefaultPort
+		}
+		return &upstream{scheme: schemeTLS, addr: addr}
+	case strings.HasPrefix(spec, "https://"):
+		return &upstream{scheme: schemeHTTPS, addr: spec}
+	default:
+		addr := spec
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr += defaultDNSPort
+		}
+		return &upstream{scheme: schemeUDP, addr: addr}
+	}
+}
+
+// NewResolverWithOptions 创建支持DoT/DoH上游的DNS解析器，dnsServers中的每一项
+// 可以是 "tls://host:port"、"https://host/dns-query" 或普通的明文UDP地址。
+// 如果所有条目都是明文UDP，则退化为NewResolver的行为。
+func NewResolverWithOptions(ttl time.Duration, dnsServers []string) *Resolver {
+	upstreams := make([]*upstream, 0, len(dnsServers))
+	manual := false
+	for _, spec := range dnsServers {
+		u := parseUpstream(spec)
+		upstreams = append(upstreams, u)
+		if u.scheme != schemeUDP {
+			manual = true
+		}
+	}
+
+	if !manual {
+		return NewResolver(ttl, dnsServers)
+	}
+
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	return &Resolver{
+		ttl:                ttl,
+		negTTL:             defaultNegTTL,
+		dnsServers:         dnsServers,
+		upstreams:          upstreams,
+		useManualUpstreams: true,
+		netResolver:        &net.Resolver{PreferGo: true},
+	}
+}
+
+// lookupUpstreams 按轮询顺序尝试每个健康的上游，直到解析成功
+func (r *Resolver) lookupUpstreams(ctx context.Context, host string) ([]net.IP, error) {
+	n := len(r.upstreams)
+	if n == 0 {
+		return nil, fmt.Errorf("lookupUpstreams: no upstream DNS servers configured")
+	}
+
+	var lastErr error
+	now := time.Now()
+	for round := 0; round < 2; round++ {
+		// 第一轮只尝试健康的上游，第二轮（若全部不健康）放宽限制兜底
+		for i := 0; i < n; i++ {
+			idx := atomic.AddUint32(&r.serverIndex, 1) - 1
+			u := r.upstreams[int(idx)%n]
+			if round == 0 && !u.healthy(now) {
+				continue
+			}
+
+			start := time.Now()
+			ips, err := r.queryUpstream(ctx, u, host)
+			r.notifyUpstreamLatency(u.addr, time.Since(start))
+			if err != nil {
+				u.markFailed()
+				lastErr = err
+				continue
+			}
+			u.markOK()
+			return ips, nil
+		}
+	}
+
+	return nil, fmt.Errorf("lookupUpstreams: all upstream DNS servers failed for %s: %w", host, lastErr)
+}
+
+// qTypes 是每次解析都会查询的记录类型
+var qTypes = [...]dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA}
+
+// queryUpstream 向单个上游查询A和AAAA记录并合并结果。DoT上游会复用同一条
+// TLS连接发出两个查询，避免为每个记录类型都重新握手
+func (r *Resolver) queryUpstream(ctx context.Context, u *upstream, host string) ([]net.IP, error) {
+	queries := make([][]byte, len(qTypes))
+	for i, qtype := range qTypes {
+		query, err := buildQuery(host, qtype, uint16(atomic.AddUint32(&r.queryID, 1)))
+		if err != nil {
+			return nil, err
+		}
+		queries[i] = query
+	}
+
+	var responses [][]byte
+	var err error
+	switch u.scheme {
+	case schemeTLS:
+		responses, err = queryDoT(ctx, u.addr, queries)
+	case schemeHTTPS:
+		responses, err = queryEach(queries, func(q []byte) ([]byte, error) {
+			return queryDoH(ctx, u.addr, q)
+		})
+	default:
+		responses, err = queryEach(queries, func(q []byte) ([]byte, error) {
+			return queryUDP(ctx, u.addr, q)
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("queryUpstream: %s: %w", u.addr, err)
+	}
+
+	var ips []net.IP
+	for _, raw := range responses {
+		parsed, err := parseResponse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("queryUpstream: %s: %w", u.addr, err)
+		}
+		ips = append(ips, parsed...)
+	}
+
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("queryUpstream: no records found for %s", host)
+	}
+	return ips, nil
+}
+
+// queryEach 依次对每个查询报文执行send，用于不复用连接的上游（UDP/DoH）
+func queryEach(queries [][]byte, send func([]byte) ([]byte, error)) ([][]byte, error) {
+	responses := make([][]byte, len(queries))
+	for i, q := range queries {
+		resp, err := send(q)
+		if err != nil {
+			return nil, err
+		}
+		responses[i] = resp
+	}
+	return responses, nil
+}
+
+// buildQuery 构造一个DNS查询报文
+func buildQuery(host string, qtype dnsmessage.Type, id uint16) ([]byte, error) {
+	fqdn := host
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, fmt.Errorf("buildQuery: invalid name %s: %w", host, err)
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: name, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	return msg.Pack()
+}
+
+// parseResponse 解析DNS响应报文，提取A/AAAA记录
+func parseResponse(buf []byte) ([]net.IP, error) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(buf); err != nil {
+		return nil, fmt.Errorf("parseResponse: unpack failed: %w", err)
+	}
+	if msg.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("parseResponse: rcode %s", msg.Header.RCode)
+	}
+
+	var ips []net.IP
+	for _, ans := range msg.Answers {
+		switch res := ans.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(res.A[:]))
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(res.AAAA[:]))
+		}
+	}
+	return ips, nil
+}
+
+// queryUDP 通过明文UDP发送查询
+func queryUDP(ctx context.Context, addr string, query []byte) ([]byte, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// queryDoT 通过一条DNS-over-TLS连接批量发送多个查询，按RFC 7858使用2字节
+// 长度前缀分帧。同一次解析的所有查询复用同一次TCP连接和TLS握手
+func queryDoT(ctx context.Context, addr string, queries [][]byte) ([][]byte, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("queryDoT: invalid address %s: %w", addr, err)
+	}
+
+	dialer := &net.Dialer{}
+	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(rawConn, &tls.Config{ServerName: host})
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := conn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("queryDoT: TLS handshake failed: %w", err)
+	}
+
+	for _, query := range queries {
+		framed := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(framed, uint16(len(query)))
+		copy(framed[2:], query)
+		if _, err := conn.Write(framed); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([][]byte, len(queries))
+	for i := range queries {
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(conn, lenBuf[:]); err != nil {
+			return nil, err
+		}
+		resp := make([]byte, binary.BigEndian.Uint16(lenBuf[:]))
+		if _, err := io.ReadFull(conn, resp); err != nil {
+			return nil, err
+		}
+		responses[i] = resp
+	}
+	return responses, nil
+}
+
+// dohClient 是发送DNS-over-HTTPS请求的共享客户端
+var dohClient = &http.Client{Timeout: dnsTimeout}
+
+// queryDoH 通过DNS-over-HTTPS发送查询，使用application/dns-message编码
+func queryDoH(ctx context.Context, url string, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := dohClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("queryDoH: unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}